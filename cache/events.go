@@ -0,0 +1,137 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// EventKind identifies the kind of mutation a CacheEvent describes.
+type EventKind int
+
+const (
+	// EventAdded is emitted when a new entry is written to the cache.
+	EventAdded EventKind = iota
+	// EventEvicted is emitted when an entry is evicted to make room for a
+	// new one, or because Resize shrank the cache.
+	EventEvicted
+	// EventInvalidated is emitted when an entry is removed through
+	// Invalidate or InvalidateByPredicate.
+	EventInvalidated
+	// EventExpired is emitted when an entry is found to be past its TTL,
+	// either lazily on access or by the background expiration sweeper.
+	EventExpired
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventAdded:
+		return "Added"
+	case EventEvicted:
+		return "Evicted"
+	case EventInvalidated:
+		return "Invalidated"
+	case EventExpired:
+		return "Expired"
+	default:
+		return "Unknown"
+	}
+}
+
+// CacheEvent describes a single mutation observed on a Cache[T].
+type CacheEvent struct {
+	Type EventKind
+	Key  string
+}
+
+// subscriberBufferSize is the capacity of the channel returned by
+// Subscribe. A subscriber that falls behind by this many events has further
+// events dropped rather than blocking the mutation that produced them.
+const subscriberBufferSize = 64
+
+// Subscribe returns a channel that receives a CacheEvent for every
+// subsequent mutation: Set/SetWithTTL/GetIfOrSet writes (EventAdded),
+// evictions (EventEvicted), Invalidate/InvalidateByPredicate calls
+// (EventInvalidated), and TTL expirations (EventExpired). Delete publishes no
+// event. The channel is never closed by the cache; it is safe to call
+// Subscribe any number of times.
+func (c *Cache[T]) Subscribe() <-chan CacheEvent {
+	ch := make(chan CacheEvent, subscriberBufferSize)
+	c.subMu.Lock()
+	c.subscribers = append(c.subscribers, ch)
+	c.subMu.Unlock()
+	return ch
+}
+
+// publish fans event out to every subscriber without blocking. A subscriber
+// that is not keeping up has the event dropped and
+// gotk_cache_subscriber_drops_total incremented instead.
+func (c *Cache[T]) publish(event CacheEvent) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+			recordSubscriberDrop(c.metrics)
+		}
+	}
+}
+
+// Invalidate removes the given keys from the cache, if present, and
+// publishes an EventInvalidated CacheEvent for each one removed. It is
+// intended for cross-controller invalidation, e.g. a Secret watch evicting
+// all cached client credentials derived from it, without racing a polling
+// loop.
+func (c *Cache[T]) Invalidate(keys ...string) {
+	c.mu.Lock()
+	var removed []string
+	for _, key := range keys {
+		n, ok := c.cache[key]
+		if !ok {
+			continue
+		}
+		c.delete(n)
+		removed = append(removed, key)
+	}
+	c.mu.Unlock()
+
+	recordRequest(c.metrics, StatusSuccess)
+	for _, key := range removed {
+		recordDecrement(c.metrics)
+		c.publish(CacheEvent{Type: EventInvalidated, Key: key})
+	}
+}
+
+// InvalidateByPredicate removes every entry for which match returns true and
+// publishes an EventInvalidated CacheEvent for each. It returns the number
+// of entries removed, so controllers can flush all cached entries whose
+// owning Kubernetes object was deleted.
+func (c *Cache[T]) InvalidateByPredicate(match func(key string, value T) bool) int {
+	c.mu.Lock()
+	var removed []string
+	for key, n := range c.cache {
+		if match(key, n.value) {
+			c.delete(n)
+			removed = append(removed, key)
+		}
+	}
+	c.mu.Unlock()
+
+	recordRequest(c.metrics, StatusSuccess)
+	for _, key := range removed {
+		recordDecrement(c.metrics)
+		c.publish(CacheEvent{Type: EventInvalidated, Key: key})
+	}
+	return len(removed)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCache_SubscribeReceivesAddedAndEvictedEvents(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](1)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	events := c.Subscribe()
+
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Eventually(events).Should(Receive(Equal(CacheEvent{Type: EventAdded, Key: "a"})))
+
+	// Capacity 1: adding "b" must evict "a".
+	g.Expect(c.Set("b", "2")).To(Succeed())
+	g.Eventually(events).Should(Receive(Equal(CacheEvent{Type: EventAdded, Key: "b"})))
+	g.Eventually(events).Should(Receive(Equal(CacheEvent{Type: EventEvicted, Key: "a"})))
+}
+
+func TestCache_InvalidatePublishesAndRemovesOnlyMatchingKeys(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Expect(c.Set("b", "2")).To(Succeed())
+
+	events := c.Subscribe()
+	c.Invalidate("a", "missing-key")
+
+	g.Eventually(events).Should(Receive(Equal(CacheEvent{Type: EventInvalidated, Key: "a"})))
+
+	_, err = c.Get("a")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("b")
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestCache_InvalidateByPredicateRemovesMatchingEntries(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[int](10)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Set("even", 2)).To(Succeed())
+	g.Expect(c.Set("odd", 3)).To(Succeed())
+
+	n := c.InvalidateByPredicate(func(_ string, v int) bool { return v%2 == 0 })
+	g.Expect(n).To(Equal(1))
+
+	_, err = c.Get("even")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("odd")
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestCache_PublishDropsOnSlowSubscriberWithoutBlocking(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Never drained, so it fills up and every event past its buffer is
+	// dropped instead of blocking the mutation that produced it.
+	slow := c.Subscribe()
+
+	for i := 0; i < subscriberBufferSize+10; i++ {
+		done := make(chan struct{})
+		go func(i int) {
+			defer close(done)
+			_ = c.Set(string(rune('a'+i%26)), "v")
+		}(i)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Set blocked on a full subscriber channel")
+		}
+	}
+
+	g.Expect(slow).To(HaveLen(subscriberBufferSize))
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import "context"
+
+// Limiter caps the rate at which GetIfOrSet is allowed to call fetch on a
+// cache miss, e.g. a *golang.org/x/time/rate.Limiter guarding calls to a
+// registry or API that many reconciled objects share. Concurrent misses on
+// the same key are coalesced before fetch is invoked, so a single key's
+// thundering herd only consumes the limiter once.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WithFetchLimiter makes GetIfOrSet call l.Wait(ctx) before invoking fetch
+// on a cache miss, so operators can cap how often upstream calls are made.
+func WithFetchLimiter(l Limiter) Options {
+	return func(o *options) error {
+		o.fetchLimiter = l
+		return nil
+	}
+}
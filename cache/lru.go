@@ -20,17 +20,27 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 )
 
-// node is a node in a doubly linked list
-// that is used to implement an LRU cache
+// node is a node in a doubly linked list, as used by the built-in eviction
+// policies to track ordering.
 type node[T any] struct {
 	value T
 	key   string
 	prev  *node[T]
 	next  *node[T]
+
+	// expiresAt is the time at which this entry should be treated as absent
+	// from the cache. The zero value means the entry never expires.
+	expiresAt time.Time
+}
+
+// expired reports whether the node's TTL, if any, has elapsed as of now.
+func (n *node[T]) expired(now time.Time) bool {
+	return !n.expiresAt.IsZero() && now.After(n.expiresAt)
 }
 
 func (n *node[T]) addNext(node *node[T]) {
@@ -41,86 +51,115 @@ func (n *node[T]) addPrev(node *node[T]) {
 	n.prev = node
 }
 
-// LRU is a thread-safe in-memory key/value store.
-// All methods are safe for concurrent use.
-// All operations are O(1). The hash map lookup is O(1) and so is the doubly
-// linked list insertion/deletion.
-//
-// The LRU is implemented as a doubly linked list, where the most recently accessed
-// item is at the front of the list and the least recently accessed item is at
-// the back. When an item is accessed, it is moved to the front of the list.
-// When the cache is full, the least recently accessed item is removed from the
-// back of the list.
-//
-//	                                  Cache
-//	           ┌───────────────────────────────────────────────────┐
-//	           │                                                   │
-//	  empty    │     obj         obj          obj          obj     │    empty
-//	┌───────┐  │  ┌───────┐   ┌───────┐     ┌───────┐   ┌───────┐  │  ┌───────┐
-//	│       │  │  │       │   │       │ ... │       │   │       │  │  │       │
-//	│ HEAD  │◄─┼─►│       │◄─►│       │◄───►│       │◄─►│       │◄─┼─►│ TAIL  │
-//	│       │  │  │       │   │       │     │       │   │       │  │  │       │
-//	└───────┘  │  └───────┘   └───────┘     └───────┘   └───────┘  │  └───────┘
-//	           │                                                   │
-//	           │                                                   │
-//	           └───────────────────────────────────────────────────┘
+// Cache is a thread-safe in-memory key/value store whose eviction order is
+// driven by a Policy[T]. All methods are safe for concurrent use, and all
+// operations are O(1): the hash map lookup is O(1) and so are the policy's
+// Touch/Insert/Evict operations.
 //
-// Use the NewLRU function to create a new cache that is ready to use.
-type LRU[T any] struct {
+// Use NewCache to create a Cache with a given Policy[T], or NewLRU for the
+// common case of least-recently-used eviction.
+type Cache[T any] struct {
 	cache    map[string]*node[T]
 	capacity int
+	policy   Policy[T]
 	metrics  *cacheMetrics
-	head     *node[T]
-	tail     *node[T]
 	mu       sync.RWMutex
+
+	// defaultTTL is applied to entries written through Set when no
+	// per-entry TTL is supplied via SetWithTTL. The zero value means
+	// entries never expire unless SetWithTTL is used directly.
+	defaultTTL time.Duration
+	// stopExpirySweep, when non-nil, signals the background expiration
+	// sweeper goroutine started by WithExpirationInterval to stop.
+	stopExpirySweep chan struct{}
+	// closeOnce guards stopExpirySweep so that Close is safe to call more
+	// than once, e.g. once from a defer and once from an explicit shutdown
+	// path.
+	closeOnce sync.Once
+
+	// subMu guards subscribers and is separate from mu. publish never
+	// blocks, so it may be called with mu held, as add and sweepExpired do.
+	subMu       sync.Mutex
+	subscribers []chan CacheEvent
+
+	// fetchLimiter, if set via WithFetchLimiter, is consulted once per
+	// coalesced group of GetIfOrSet misses before fetch is invoked.
+	fetchLimiter Limiter
+	// flightMu guards inflight and is independent of mu: a fetch runs
+	// without holding the main cache lock, so unrelated keys never block on
+	// it.
+	flightMu sync.Mutex
+	inflight map[string]*call[T]
 }
 
-var _ Store[any] = &LRU[any]{}
+var _ Store[any] = &Cache[any]{}
 
-// NewLRU creates a new LRU cache with the given capacity.
-func NewLRU[T any](capacity int, opts ...Options) (*LRU[T], error) {
+// NewCache creates a new Cache with the given capacity and eviction policy,
+// e.g. NewLRUPolicy, NewLFUPolicy or NewFIFOPolicy.
+func NewCache[T any](capacity int, policy Policy[T], opts ...Options) (*Cache[T], error) {
 	opt, err := makeOptions(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply options: %w", err)
 	}
 
-	head := &node[T]{}
-	tail := &node[T]{}
-	head.addNext(tail)
-	tail.addPrev(head)
-
-	lru := &LRU[T]{
-		cache:    make(map[string]*node[T]),
-		capacity: capacity,
-		head:     head,
-		tail:     tail,
+	c := &Cache[T]{
+		cache:        make(map[string]*node[T]),
+		capacity:     capacity,
+		policy:       policy,
+		defaultTTL:   opt.defaultTTL,
+		fetchLimiter: opt.fetchLimiter,
 	}
 
 	if opt.registerer != nil {
-		lru.metrics = newCacheMetrics(opt.metricsPrefix, opt.registerer, opts...)
+		c.metrics = newCacheMetrics(opt.metricsPrefix, opt.registerer, opts...)
 	}
 
-	return lru, nil
+	if opt.expirationInterval > 0 {
+		c.startExpirySweeper(opt.expirationInterval)
+	}
+
+	return c, nil
 }
 
-// Set an item in the cache, existing index will be overwritten.
-func (c *LRU[T]) Set(key string, value T) error {
+// Set an item in the cache, existing index will be overwritten. If
+// WithDefaultTTL was configured, the entry expires after that duration; use
+// SetWithTTL to override it on a per-entry basis.
+func (c *Cache[T]) Set(key string, value T) error {
+	var expiresAt time.Time
+	if c.defaultTTL > 0 {
+		expiresAt = time.Now().Add(c.defaultTTL)
+	}
+	return c.set(key, value, expiresAt)
+}
+
+// SetWithTTL sets an item in the cache that is treated as absent once ttl
+// has elapsed, overriding the cache's default TTL (if any) for this entry.
+// A ttl of zero means the entry never expires.
+func (c *Cache[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	return c.set(key, value, expiresAt)
+}
+
+func (c *Cache[T]) set(key string, value T, expiresAt time.Time) error {
 	// if node is already in cache, return error
 	c.mu.Lock()
 	newNode, ok := c.cache[key]
 	if ok {
 		c.delete(newNode)
-		_ = c.add(&node[T]{key: key, value: value})
+		_ = c.add(&node[T]{key: key, value: value, expiresAt: expiresAt})
 		c.mu.Unlock()
 		recordRequest(c.metrics, StatusSuccess)
 		return nil
 	}
 
-	evicted := c.add(&node[T]{key: key, value: value})
+	evicted := c.add(&node[T]{key: key, value: value, expiresAt: expiresAt})
 	c.mu.Unlock()
 	recordRequest(c.metrics, StatusSuccess)
 	if evicted {
-		recordEviction(c.metrics)
+		recordEviction(c.metrics, c.policy.Name())
 		return nil
 	}
 	recordItemIncrement(c.metrics)
@@ -129,22 +168,22 @@ func (c *LRU[T]) Set(key string, value T) error {
 
 // GetIfOrSet returns an item in the cache for the given key if present and
 // if the condition is satisfied, or calls the fetch function to get a new
-// item and stores it in the cache. The operation is thread-safe and atomic.
-// The boolean return value indicates whether the item was retrieved from
-// the cache.
-func (c *LRU[T]) GetIfOrSet(ctx context.Context,
+// item and stores it in the cache. The operation is atomic: concurrent
+// misses on the same key are coalesced into a single call to fetch, with the
+// winner's value inserted into the cache and every other caller observing
+// that same value with ok=false. fetch itself runs without holding the
+// cache's lock, so it never blocks unrelated keys. The boolean return value
+// indicates whether the item was retrieved from the cache.
+func (c *Cache[T]) GetIfOrSet(ctx context.Context,
 	key string,
 	condition func(T) bool,
 	fetch func(context.Context) (T, error),
 	opts ...Options,
 ) (value T, ok bool, err error) {
 
-	var existed, evicted bool
+	var existed, evicted, expired, coalesced bool
 
-	c.mu.Lock()
 	defer func() {
-		c.mu.Unlock()
-
 		var o storeOptions
 		o.apply(opts...)
 
@@ -162,10 +201,18 @@ func (c *LRU[T]) GetIfOrSet(ctx context.Context,
 			c.RecordCacheEvent(event, obj.Kind, obj.Name, obj.Namespace, obj.Operation)
 		}
 		if evicted {
-			recordEviction(c.metrics)
-		} else if !existed && err == nil {
+			recordEviction(c.metrics, c.policy.Name())
+		} else if !existed && !coalesced && err == nil {
 			recordItemIncrement(c.metrics)
 		}
+		if expired {
+			recordDecrement(c.metrics)
+			recordExpiration(c.metrics)
+			c.publish(CacheEvent{Type: EventExpired, Key: key})
+		}
+		if coalesced {
+			recordCoalescedFetch(c.metrics)
+		}
 
 		// Print debug logs. The involved object should already be set in the context logger.
 		switch l := logr.FromContextOrDiscard(ctx).V(1).WithValues("key", key); {
@@ -180,98 +227,128 @@ func (c *LRU[T]) GetIfOrSet(ctx context.Context,
 		}
 	}()
 
+	c.mu.Lock()
 	var curNode *node[T]
 	curNode, existed = c.cache[key]
 
 	if existed {
-		c.delete(curNode)
-		if condition(curNode.value) {
-			_ = c.add(curNode)
+		if curNode.expired(time.Now()) {
+			c.delete(curNode)
+			existed, expired = false, true
+		} else if condition(curNode.value) {
+			c.policy.Touch(curNode)
 			value, ok = curNode.value, true
+			c.mu.Unlock()
 			return
+		} else {
+			c.delete(curNode)
 		}
 	}
+	c.mu.Unlock()
 
-	value, err = fetch(ctx)
+	value, err, coalesced = c.singleflightFetch(ctx, key, fetch)
 	if err != nil {
 		var zero T
 		value = zero
 		return
 	}
+	if coalesced {
+		// Another goroutine's fetch already inserted this value; we only
+		// observe it.
+		return
+	}
 
-	evicted = c.add(&node[T]{key: key, value: value})
+	var expiresAt time.Time
+	if c.defaultTTL > 0 {
+		expiresAt = time.Now().Add(c.defaultTTL)
+	}
+	c.mu.Lock()
+	if cur, found := c.cache[key]; found {
+		// A concurrent Set wrote key while fetch ran; replace that entry.
+		c.delete(cur)
+		existed = true
+	}
+	evicted = c.add(&node[T]{key: key, value: value, expiresAt: expiresAt})
+	c.mu.Unlock()
 
 	return
 }
 
-func (c *LRU[T]) add(node *node[T]) (evicted bool) {
-	prev := c.tail.prev
-	prev.addNext(node)
-	c.tail.addPrev(node)
-	node.addPrev(prev)
-	node.addNext(c.tail)
-
-	c.cache[node.key] = node
+func (c *Cache[T]) add(n *node[T]) (evicted bool) {
+	// Make room before inserting n: under LFU a brand-new node ranks lowest,
+	// so evicting after the insert would pick n itself.
+	var victim *node[T]
+	if len(c.cache) >= c.capacity {
+		victim = c.policy.Evict()
+	}
+	c.cache[n.key] = n
+	c.policy.Insert(n)
+	c.publish(CacheEvent{Type: EventAdded, Key: n.key})
 
-	if len(c.cache) > c.capacity {
-		c.delete(c.head.next)
+	if victim == nil && len(c.cache) > c.capacity {
+		victim = c.policy.Evict()
+	}
+	if victim != nil {
+		delete(c.cache, victim.key)
+		c.publish(CacheEvent{Type: EventEvicted, Key: victim.key})
 		return true
 	}
 	return false
 }
 
-// Delete removes a node from the list
-func (c *LRU[T]) Delete(key string) error {
-	// if node is head or tail, do nothing
-	if key == c.head.key || key == c.tail.key {
-		recordRequest(c.metrics, StatusSuccess)
-		return nil
-	}
-
+// Delete removes a node from the cache.
+func (c *Cache[T]) Delete(key string) error {
 	c.mu.Lock()
 	// if node is not in cache, do nothing
-	node, ok := c.cache[key]
+	n, ok := c.cache[key]
 	if !ok {
 		c.mu.Unlock()
 		recordRequest(c.metrics, StatusSuccess)
 		return nil
 	}
 
-	c.delete(node)
+	c.delete(n)
 	c.mu.Unlock()
 	recordRequest(c.metrics, StatusSuccess)
 	recordDecrement(c.metrics)
 	return nil
 }
 
-func (c *LRU[T]) delete(node *node[T]) {
-	node.prev.next, node.next.prev = node.next, node.prev
-	node.next, node.prev = nil, nil // avoid memory leaks
-	delete(c.cache, node.key)
+func (c *Cache[T]) delete(n *node[T]) {
+	c.policy.Remove(n)
+	delete(c.cache, n.key)
 }
 
-// Get returns an item in the cache for the given key. If no item is found, an
-// error is returned.
+// Get returns an item in the cache for the given key. If no item is found, or
+// the item has expired, ErrNotFound is returned.
 // The caller can record cache hit or miss based on the result with
-// LRU.RecordCacheEvent().
-func (c *LRU[T]) Get(key string) (T, error) {
+// Cache.RecordCacheEvent().
+func (c *Cache[T]) Get(key string) (T, error) {
 	var res T
 	c.mu.Lock()
-	node, ok := c.cache[key]
+	n, ok := c.cache[key]
 	if !ok {
 		c.mu.Unlock()
 		recordRequest(c.metrics, StatusSuccess)
 		return res, ErrNotFound
 	}
-	c.delete(node)
-	_ = c.add(node)
+	if n.expired(time.Now()) {
+		c.delete(n)
+		c.mu.Unlock()
+		recordRequest(c.metrics, StatusSuccess)
+		recordDecrement(c.metrics)
+		recordExpiration(c.metrics)
+		c.publish(CacheEvent{Type: EventExpired, Key: key})
+		return res, ErrNotFound
+	}
+	c.policy.Touch(n)
 	c.mu.Unlock()
 	recordRequest(c.metrics, StatusSuccess)
-	return node.value, nil
+	return n.value, nil
 }
 
 // ListKeys returns a list of keys in the cache.
-func (c *LRU[T]) ListKeys() ([]string, error) {
+func (c *Cache[T]) ListKeys() ([]string, error) {
 	keys := make([]string, 0, len(c.cache))
 	c.mu.RLock()
 	for k := range c.cache {
@@ -283,7 +360,7 @@ func (c *LRU[T]) ListKeys() ([]string, error) {
 }
 
 // Resize resizes the cache and returns the number of items removed.
-func (c *LRU[T]) Resize(size int) (int, error) {
+func (c *Cache[T]) Resize(size int) (int, error) {
 	if size <= 0 {
 		recordRequest(c.metrics, StatusFailure)
 		return 0, ErrInvalidSize
@@ -300,8 +377,13 @@ func (c *LRU[T]) Resize(size int) (int, error) {
 	}
 
 	for i := 0; i < overflow; i++ {
-		c.delete(c.head.next)
-		recordEviction(c.metrics)
+		victim := c.policy.Evict()
+		if victim == nil {
+			break
+		}
+		delete(c.cache, victim.key)
+		recordEviction(c.metrics, c.policy.Name())
+		c.publish(CacheEvent{Type: EventEvicted, Key: victim.key})
 	}
 	c.mu.Unlock()
 	recordRequest(c.metrics, StatusSuccess)
@@ -310,12 +392,84 @@ func (c *LRU[T]) Resize(size int) (int, error) {
 
 // RecordCacheEvent records a cache event (cache_miss or cache_hit) with kind,
 // name and namespace of the associated object being reconciled.
-func (c *LRU[T]) RecordCacheEvent(event, kind, name, namespace, operation string) {
+func (c *Cache[T]) RecordCacheEvent(event, kind, name, namespace, operation string) {
 	recordCacheEvent(c.metrics, event, kind, name, namespace, operation)
 }
 
 // DeleteCacheEvent deletes the cache event (cache_miss or cache_hit) metric for
 // the associated object being reconciled, given their kind, name and namespace.
-func (c *LRU[T]) DeleteCacheEvent(event, kind, name, namespace, operation string) {
+func (c *Cache[T]) DeleteCacheEvent(event, kind, name, namespace, operation string) {
 	deleteCacheEvent(c.metrics, event, kind, name, namespace, operation)
 }
+
+// startExpirySweeper launches a goroutine that walks the cache every
+// interval, evicting any entry whose TTL has elapsed. It runs until Close is
+// called.
+func (c *Cache[T]) startExpirySweeper(interval time.Duration) {
+	c.stopExpirySweep = make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.sweepExpired()
+			case <-c.stopExpirySweep:
+				return
+			}
+		}
+	}()
+}
+
+// sweepExpired walks the cache once, removing every entry whose TTL has
+// elapsed.
+func (c *Cache[T]) sweepExpired() {
+	now := time.Now()
+	c.mu.Lock()
+	for _, n := range c.cache {
+		if n.expired(now) {
+			c.delete(n)
+			recordDecrement(c.metrics)
+			recordExpiration(c.metrics)
+			c.publish(CacheEvent{Type: EventExpired, Key: n.key})
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Close stops the background expiration sweeper started by
+// WithExpirationInterval, if any. It is safe to call Close on a cache that
+// was created without that option, and safe to call more than once.
+func (c *Cache[T]) Close() error {
+	c.closeOnce.Do(func() {
+		if c.stopExpirySweep != nil {
+			close(c.stopExpirySweep)
+		}
+	})
+	return nil
+}
+
+// LRU is a thread-safe in-memory key/value store using a least-recently-used
+// eviction policy: when the cache is full, the least recently accessed item
+// is evicted to make room for a new one.
+//
+// LRU is a thin wrapper around Cache[T] using NewLRUPolicy, kept for
+// backward compatibility; new code that wants a different eviction
+// strategy should call NewCache directly with NewLFUPolicy or
+// NewFIFOPolicy.
+//
+// Use the NewLRU function to create a new cache that is ready to use.
+type LRU[T any] struct {
+	*Cache[T]
+}
+
+var _ Store[any] = &LRU[any]{}
+
+// NewLRU creates a new LRU cache with the given capacity.
+func NewLRU[T any](capacity int, opts ...Options) (*LRU[T], error) {
+	c, err := NewCache[T](capacity, NewLRUPolicy[T](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &LRU[T]{c}, nil
+}
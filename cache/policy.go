@@ -0,0 +1,241 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+// Policy decides which node Cache[T] evicts when it is full, and how a
+// node's standing changes as it is touched, inserted or removed. It is what
+// lets Cache[T] be driven by strategies other than least-recently-used, such
+// as least-frequently-used or first-in-first-out.
+//
+// Implementations are not safe for concurrent use on their own; Cache[T]
+// serializes all access to a Policy under its own mutex.
+type Policy[T any] interface {
+	// Touch records an access to n, for policies (like LRU and LFU) whose
+	// eviction order depends on access patterns.
+	Touch(n *node[T])
+	// Insert registers a newly added node.
+	Insert(n *node[T])
+	// Remove unregisters a node, e.g. because it was deleted, evicted,
+	// invalidated or expired.
+	Remove(n *node[T])
+	// Evict selects and unregisters the node that should be reclaimed to
+	// make room for a new entry. It returns nil if there is nothing to
+	// evict.
+	Evict() *node[T]
+	// Name identifies the policy, e.g. for the policy label on
+	// gotk_cache_evictions_total.
+	Name() string
+}
+
+// unlink removes n from whatever doubly linked list it is currently part of.
+func unlink[T any](n *node[T]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+	n.prev, n.next = nil, nil
+}
+
+// lruPolicy evicts the least-recently-used node. It is the policy used by
+// NewLRU.
+type lruPolicy[T any] struct {
+	// head.next is the least-recently-used node, tail.prev is the
+	// most-recently-used one.
+	head *node[T]
+	tail *node[T]
+}
+
+// NewLRUPolicy returns a Policy[T] that evicts the least-recently-used node.
+func NewLRUPolicy[T any]() Policy[T] {
+	head, tail := &node[T]{}, &node[T]{}
+	head.addNext(tail)
+	tail.addPrev(head)
+	return &lruPolicy[T]{head: head, tail: tail}
+}
+
+func (p *lruPolicy[T]) pushMRU(n *node[T]) {
+	prev := p.tail.prev
+	prev.addNext(n)
+	n.addPrev(prev)
+	n.addNext(p.tail)
+	p.tail.addPrev(n)
+}
+
+func (p *lruPolicy[T]) Insert(n *node[T]) { p.pushMRU(n) }
+
+func (p *lruPolicy[T]) Touch(n *node[T]) {
+	unlink(n)
+	p.pushMRU(n)
+}
+
+func (p *lruPolicy[T]) Remove(n *node[T]) { unlink(n) }
+
+func (p *lruPolicy[T]) Evict() *node[T] {
+	if p.head.next == p.tail {
+		return nil
+	}
+	victim := p.head.next
+	unlink(victim)
+	return victim
+}
+
+func (p *lruPolicy[T]) Name() string { return "lru" }
+
+// fifoPolicy evicts the node that was inserted first, regardless of how
+// often or recently it has been accessed since.
+type fifoPolicy[T any] struct {
+	head *node[T]
+	tail *node[T]
+}
+
+// NewFIFOPolicy returns a Policy[T] that evicts nodes in the order they were
+// inserted.
+func NewFIFOPolicy[T any]() Policy[T] {
+	head, tail := &node[T]{}, &node[T]{}
+	head.addNext(tail)
+	tail.addPrev(head)
+	return &fifoPolicy[T]{head: head, tail: tail}
+}
+
+func (p *fifoPolicy[T]) Insert(n *node[T]) {
+	prev := p.tail.prev
+	prev.addNext(n)
+	n.addPrev(prev)
+	n.addNext(p.tail)
+	p.tail.addPrev(n)
+}
+
+// Touch is a no-op: FIFO eviction order only depends on insertion order.
+func (p *fifoPolicy[T]) Touch(n *node[T]) {}
+
+func (p *fifoPolicy[T]) Remove(n *node[T]) { unlink(n) }
+
+func (p *fifoPolicy[T]) Evict() *node[T] {
+	if p.head.next == p.tail {
+		return nil
+	}
+	victim := p.head.next
+	unlink(victim)
+	return victim
+}
+
+func (p *fifoPolicy[T]) Name() string { return "fifo" }
+
+// lfuBucket is the doubly linked list of nodes that currently share a given
+// access frequency.
+type lfuBucket[T any] struct {
+	head *node[T]
+	tail *node[T]
+}
+
+func newLFUBucket[T any]() *lfuBucket[T] {
+	head, tail := &node[T]{}, &node[T]{}
+	head.addNext(tail)
+	tail.addPrev(head)
+	return &lfuBucket[T]{head: head, tail: tail}
+}
+
+func (b *lfuBucket[T]) empty() bool { return b.head.next == b.tail }
+
+func (b *lfuBucket[T]) pushFront(n *node[T]) {
+	next := b.head.next
+	b.head.addNext(n)
+	n.addPrev(b.head)
+	n.addNext(next)
+	next.addPrev(n)
+}
+
+func (b *lfuBucket[T]) back() *node[T] {
+	if b.empty() {
+		return nil
+	}
+	return b.tail.prev
+}
+
+// lfuPolicy evicts the least-frequently-used node. Among nodes with equal
+// frequency, the one that reached that frequency first is evicted, as each
+// bucket is pushed at the front and evicted from the back. Frequencies are
+// tracked in buckets indexed by freq count, with a running minFreq, giving
+// O(1) Touch/Insert/Evict - the approach commonly known as "LFU in O(1)".
+type lfuPolicy[T any] struct {
+	freq    map[*node[T]]int
+	buckets map[int]*lfuBucket[T]
+	minFreq int
+}
+
+// NewLFUPolicy returns a Policy[T] that evicts the least-frequently-used
+// node.
+func NewLFUPolicy[T any]() Policy[T] {
+	return &lfuPolicy[T]{
+		freq:    make(map[*node[T]]int),
+		buckets: make(map[int]*lfuBucket[T]),
+	}
+}
+
+func (p *lfuPolicy[T]) bucket(freq int) *lfuBucket[T] {
+	b, ok := p.buckets[freq]
+	if !ok {
+		b = newLFUBucket[T]()
+		p.buckets[freq] = b
+	}
+	return b
+}
+
+func (p *lfuPolicy[T]) Insert(n *node[T]) {
+	p.freq[n] = 1
+	p.bucket(1).pushFront(n)
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy[T]) Touch(n *node[T]) {
+	freq, ok := p.freq[n]
+	if !ok {
+		p.Insert(n)
+		return
+	}
+
+	unlink(n)
+	if old := p.buckets[freq]; old.empty() && p.minFreq == freq {
+		p.minFreq++
+	}
+
+	p.freq[n] = freq + 1
+	p.bucket(freq + 1).pushFront(n)
+}
+
+func (p *lfuPolicy[T]) Remove(n *node[T]) {
+	freq, ok := p.freq[n]
+	if !ok {
+		return
+	}
+	unlink(n)
+	delete(p.freq, n)
+}
+
+func (p *lfuPolicy[T]) Evict() *node[T] {
+	if len(p.freq) == 0 {
+		return nil
+	}
+	for {
+		if b, ok := p.buckets[p.minFreq]; ok && !b.empty() {
+			victim := b.back()
+			unlink(victim)
+			delete(p.freq, victim)
+			return victim
+		}
+		p.minFreq++
+	}
+}
+
+func (p *lfuPolicy[T]) Name() string { return "lfu" }
@@ -0,0 +1,132 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLRUPolicy_EvictsLeastRecentlyUsed(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewCache[string](2, NewLRUPolicy[string]())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Expect(c.Set("b", "2")).To(Succeed())
+	// Touch "a" so "b" becomes the least recently used.
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("c", "3")).To(Succeed())
+
+	_, err = c.Get("b")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("c")
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestFIFOPolicy_EvictsInInsertionOrder(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewCache[string](2, NewFIFOPolicy[string]())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Expect(c.Set("b", "2")).To(Succeed())
+	// Touching "a" must not protect it: FIFO only cares about insertion order.
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("c", "3")).To(Succeed())
+
+	_, err = c.Get("a")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("b")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("c")
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestLFUPolicy_EvictsLeastFrequentlyUsed(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewCache[string](2, NewLFUPolicy[string]())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Expect(c.Set("b", "2")).To(Succeed())
+
+	// Access "a" twice more than "b" so "b" is the least-frequently-used.
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("b")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("c", "3")).To(Succeed())
+
+	_, err = c.Get("b")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("c")
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestLFUPolicy_MinFreqTracksAcrossRepeatedEvictions(t *testing.T) {
+	g := NewWithT(t)
+
+	// Regression test for the O(1) minFreq bookkeeping across several
+	// rounds: minFreq must reset to 1 on every Insert and correctly skip
+	// past buckets that have since emptied out, or Evict would pick the
+	// wrong victim (or spin on a stale bucket) once the cache has cycled a
+	// few times.
+	c, err := NewCache[string](2, NewLFUPolicy[string]())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Set("a", "1")).To(Succeed())
+	g.Expect(c.Set("b", "2")).To(Succeed())
+
+	// Bump "a" to freq 2, leaving "b" as the sole freq-1 occupant.
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Inserting "c" resets minFreq to 1, so "c" and "b" tie at freq 1;
+	// "b" is evicted as it's further back in the freq-1 bucket.
+	g.Expect(c.Set("c", "3")).To(Succeed())
+	_, err = c.Get("b")
+	g.Expect(err).To(MatchError(ErrNotFound))
+
+	// Bump "a" again so "c" is now the sole freq-1 occupant.
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Inserting "d" must evict "c" (freq 1), not "a" (freq 3).
+	g.Expect(c.Set("d", "4")).To(Succeed())
+	_, err = c.Get("c")
+	g.Expect(err).To(MatchError(ErrNotFound))
+	_, err = c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Get("d")
+	g.Expect(err).ToNot(HaveOccurred())
+}
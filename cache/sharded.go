@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// ShardedLRU is a thread-safe in-memory key/value store that fans keys out
+// across a fixed number of independent LRU[T] shards, each guarded by its
+// own mutex and holding its own slice of the overall capacity. This reduces
+// lock contention compared to a single LRU[T], whose one mutex otherwise
+// serializes every GetIfOrSet call cache-wide, under highly concurrent
+// reconciles.
+//
+// Because eviction is local to a shard, ShardedLRU only approximates the
+// requested total capacity: a shard holding disproportionately many hot
+// keys evicts independently of the others.
+type ShardedLRU[T any] struct {
+	shards []*LRU[T]
+}
+
+var _ Store[any] = &ShardedLRU[any]{}
+
+// NewShardedLRU creates a ShardedLRU with the given total capacity spread
+// evenly, as ceil(capacity/shards), across the given number of shards. A
+// single set of metrics is registered and aggregated across all shards.
+func NewShardedLRU[T any](capacity, shards int, opts ...Options) (*ShardedLRU[T], error) {
+	if shards <= 0 {
+		return nil, ErrInvalidSize
+	}
+
+	opt, err := makeOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply options: %w", err)
+	}
+
+	var metrics *cacheMetrics
+	if opt.registerer != nil {
+		metrics = newCacheMetrics(opt.metricsPrefix, opt.registerer, opts...)
+	}
+
+	shardCapacity := ceilDiv(capacity, shards)
+	s := &ShardedLRU[T]{shards: make([]*LRU[T], shards)}
+	for i := range s.shards {
+		c := &Cache[T]{
+			cache:        make(map[string]*node[T]),
+			capacity:     shardCapacity,
+			policy:       NewLRUPolicy[T](),
+			metrics:      metrics,
+			defaultTTL:   opt.defaultTTL,
+			fetchLimiter: opt.fetchLimiter,
+		}
+		if opt.expirationInterval > 0 {
+			c.startExpirySweeper(opt.expirationInterval)
+		}
+		s.shards[i] = &LRU[T]{c}
+	}
+
+	return s, nil
+}
+
+func ceilDiv(a, b int) int {
+	if a <= 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+// shardFor returns the shard that owns key, using fnv-1a to spread keys
+// evenly without the overhead of a cryptographic hash.
+func (s *ShardedLRU[T]) shardFor(key string) *LRU[T] {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum64()%uint64(len(s.shards))]
+}
+
+// Set an item in the cache, existing index will be overwritten.
+func (s *ShardedLRU[T]) Set(key string, value T) error {
+	return s.shardFor(key).Set(key, value)
+}
+
+// Get returns an item in the cache for the given key. If no item is found,
+// an error is returned.
+func (s *ShardedLRU[T]) Get(key string) (T, error) {
+	return s.shardFor(key).Get(key)
+}
+
+// GetIfOrSet behaves like LRU.GetIfOrSet, scoped to the shard that owns key.
+// Concurrent misses on different keys in different shards never contend on
+// the same mutex.
+func (s *ShardedLRU[T]) GetIfOrSet(ctx context.Context,
+	key string,
+	condition func(T) bool,
+	fetch func(context.Context) (T, error),
+	opts ...Options,
+) (T, bool, error) {
+	return s.shardFor(key).GetIfOrSet(ctx, key, condition, fetch, opts...)
+}
+
+// Delete removes key from whichever shard owns it.
+func (s *ShardedLRU[T]) Delete(key string) error {
+	return s.shardFor(key).Delete(key)
+}
+
+// ListKeys returns a list of keys across all shards.
+func (s *ShardedLRU[T]) ListKeys() ([]string, error) {
+	var keys []string
+	for _, shard := range s.shards {
+		k, err := shard.ListKeys()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k...)
+	}
+	return keys, nil
+}
+
+// Close stops the background expiration sweeper goroutine of every shard, if
+// WithExpirationInterval was configured. It is safe to call Close on a
+// ShardedLRU created without that option.
+func (s *ShardedLRU[T]) Close() error {
+	for _, shard := range s.shards {
+		if err := shard.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resize proportionally rebalances capacity across shards so that the sum
+// of shard capacities is approximately size, and returns the total number
+// of items removed across all shards.
+func (s *ShardedLRU[T]) Resize(size int) (int, error) {
+	if size <= 0 {
+		return 0, ErrInvalidSize
+	}
+
+	shardCapacity := ceilDiv(size, len(s.shards))
+	var removed int
+	for _, shard := range s.shards {
+		n, err := shard.Resize(shardCapacity)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// RecordCacheEvent records a cache event (cache_miss or cache_hit) with kind,
+// name and namespace of the associated object being reconciled.
+func (s *ShardedLRU[T]) RecordCacheEvent(event, kind, name, namespace, operation string) {
+	if len(s.shards) == 0 {
+		return
+	}
+	// All shards share the same *cacheMetrics, so recording once is enough.
+	s.shards[0].RecordCacheEvent(event, kind, name, namespace, operation)
+}
+
+// DeleteCacheEvent deletes the cache event (cache_miss or cache_hit) metric
+// for the associated object being reconciled, given their kind, name and
+// namespace.
+func (s *ShardedLRU[T]) DeleteCacheEvent(event, kind, name, namespace, operation string) {
+	if len(s.shards) == 0 {
+		return
+	}
+	s.shards[0].DeleteCacheEvent(event, kind, name, namespace, operation)
+}
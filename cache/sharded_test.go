@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// benchmarkGetIfOrSet drives a mixed GetIfOrSet workload, with enough
+// distinct keys that most calls are hits but some force a fetch, across
+// many goroutines so lock contention (or the lack of it) dominates the
+// result.
+func benchmarkGetIfOrSet(b *testing.B, store Store[int]) {
+	ctx := context.Background()
+	condition := func(int) bool { return true }
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := strconv.Itoa(i % 2000)
+			i++
+			_, _, _ = store.GetIfOrSet(ctx, key, condition, func(context.Context) (int, error) {
+				return i, nil
+			})
+		}
+	})
+}
+
+func TestShardedLRU_CloseStopsEveryShardsExpirySweeper(t *testing.T) {
+	g := NewWithT(t)
+
+	before := runtime.NumGoroutine()
+
+	const shards = 8
+	s, err := NewShardedLRU[string](10, shards, WithExpirationInterval(time.Millisecond))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Eventually(runtime.NumGoroutine).WithTimeout(time.Second).Should(BeNumerically(">=", before+shards))
+
+	g.Expect(s.Close()).To(Succeed())
+
+	g.Eventually(runtime.NumGoroutine).WithTimeout(time.Second).Should(BeNumerically("<=", before))
+}
+
+func TestShardedLRU_CloseIsSafeWithoutExpirationInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	s, err := NewShardedLRU[string](10, 4)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(s.Close()).To(Succeed())
+}
+
+func TestShardedLRU_CloseIsIdempotent(t *testing.T) {
+	g := NewWithT(t)
+
+	s, err := NewShardedLRU[string](10, 4, WithExpirationInterval(5*time.Millisecond))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(s.Close()).To(Succeed())
+	g.Expect(func() { _ = s.Close() }).NotTo(Panic())
+}
+
+func BenchmarkLRU_GetIfOrSet(b *testing.B) {
+	store, err := NewLRU[int](1000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGetIfOrSet(b, store)
+}
+
+func BenchmarkShardedLRU_GetIfOrSet(b *testing.B) {
+	for _, shards := range []int{4, 16, 64} {
+		shards := shards
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store, err := NewShardedLRU[int](1000, shards)
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchmarkGetIfOrSet(b, store)
+		})
+	}
+}
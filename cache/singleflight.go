@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// call is a fetch in flight (or already completed) for a single key, in the
+// style of golang.org/x/sync/singleflight.Group.
+type call[T any] struct {
+	wg    sync.WaitGroup
+	value T
+	err   error
+}
+
+// errFetchPanicked is wrapped into in.err when fetch panics, so that
+// goroutines coalesced onto the call observe a failure rather than a
+// "successful" zero-value result once in.wg.Done unblocks them.
+var errFetchPanicked = errors.New("singleflight: fetch panicked")
+
+// singleflightFetch runs fetch for key, coalescing concurrent calls for the
+// same key into a single invocation of fetch: a caller that arrives while a
+// fetch for key is already in flight blocks on it and observes the same
+// (value, err) once it completes, instead of triggering its own call to
+// fetch. This avoids a thundering herd of duplicate upstream calls when many
+// objects are reconciled against the same cold cache key at once.
+//
+// The returned coalesced bool reports whether this call rode another
+// goroutine's fetch rather than performing its own.
+func (c *Cache[T]) singleflightFetch(ctx context.Context, key string, fetch func(context.Context) (T, error)) (value T, err error, coalesced bool) {
+	c.flightMu.Lock()
+	if in, ok := c.inflight[key]; ok {
+		c.flightMu.Unlock()
+		in.wg.Wait()
+		return in.value, in.err, true
+	}
+
+	in := new(call[T])
+	in.wg.Add(1)
+	if c.inflight == nil {
+		c.inflight = make(map[string]*call[T])
+	}
+	c.inflight[key] = in
+	c.flightMu.Unlock()
+
+	recordInflightFetchChange(c.metrics, 1)
+
+	// Guarantee cleanup even if fetch panics: without it, a panicking fetch
+	// would leave this call permanently registered in c.inflight, and every
+	// future GetIfOrSet for key would coalesce onto it and block forever on
+	// in.wg.Wait(), wedging the key for the life of the process.
+	defer func() {
+		recordInflightFetchChange(c.metrics, -1)
+
+		c.flightMu.Lock()
+		delete(c.inflight, key)
+		c.flightMu.Unlock()
+
+		in.wg.Done()
+	}()
+
+	// Record a sentinel error for any panic before the cleanup above runs
+	// in.wg.Done(): otherwise goroutines coalesced onto this call would wake
+	// up and observe in.value/in.err at their zero values, i.e. a
+	// "successful" fetch of nothing, instead of the failure that actually
+	// happened. Deferred after the cleanup above so it runs first, then lets
+	// the panic continue propagating to this goroutine's own caller.
+	defer func() {
+		if r := recover(); r != nil {
+			in.err = fmt.Errorf("%w: %v", errFetchPanicked, r)
+			panic(r)
+		}
+	}()
+
+	if c.fetchLimiter != nil {
+		if waitErr := c.fetchLimiter.Wait(ctx); waitErr != nil {
+			in.err = waitErr
+		} else {
+			in.value, in.err = fetch(ctx)
+		}
+	} else {
+		in.value, in.err = fetch(ctx)
+	}
+
+	return in.value, in.err, false
+}
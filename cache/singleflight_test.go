@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCache_GetIfOrSetCoalescesConcurrentMisses(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ctx := context.Background()
+	neverHit := func(string) bool { return false }
+
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "fetched", nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	oks := make([]bool, callers)
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			v, ok, err := c.GetIfOrSet(ctx, "k", neverHit, fetch)
+			g.Expect(err).ToNot(HaveOccurred())
+			results[i], oks[i] = v, ok
+		}(i)
+	}
+
+	// Give every goroutine a chance to arrive and coalesce onto the single
+	// in-flight fetch before letting it complete.
+	g.Eventually(func() int32 { return atomic.LoadInt32(&calls) }).Should(Equal(int32(1)))
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)), "fetch must be coalesced into exactly one call")
+	for i := 0; i < callers; i++ {
+		g.Expect(results[i]).To(Equal("fetched"))
+	}
+
+	v, err := c.Get("k")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(v).To(Equal("fetched"))
+}
+
+func TestCache_SingleflightFetchCleansUpAfterPanic(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ctx := context.Background()
+	neverHit := func(string) bool { return false }
+
+	func() {
+		defer func() { _ = recover() }()
+		_, _, _ = c.GetIfOrSet(ctx, "k", neverHit, func(context.Context) (string, error) {
+			panic("boom")
+		})
+	}()
+
+	// A panicking fetch must not leave the key wedged: a subsequent
+	// GetIfOrSet for the same key must run its own fetch rather than
+	// blocking forever on the dead call.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, _, err := c.GetIfOrSet(ctx, "k", neverHit, func(context.Context) (string, error) {
+			return "recovered", nil
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(v).To(Equal("recovered"))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetIfOrSet blocked forever on a key wedged by a prior panicking fetch")
+	}
+}
+
+func TestCache_CoalescedWaiterObservesErrorWhenFetchPanics(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ctx := context.Background()
+	neverHit := func(string) bool { return false }
+
+	release := make(chan struct{})
+	winnerStarted := make(chan struct{})
+	winnerDone := make(chan struct{})
+	go func() {
+		defer close(winnerDone)
+		defer func() { _ = recover() }()
+		_, _, _ = c.GetIfOrSet(ctx, "k", neverHit, func(context.Context) (string, error) {
+			close(winnerStarted)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-winnerStarted
+
+	var (
+		value   string
+		ok      bool
+		waitErr error
+	)
+	waiterDone := make(chan struct{})
+	go func() {
+		defer close(waiterDone)
+		value, ok, waitErr = c.GetIfOrSet(ctx, "k", neverHit, func(context.Context) (string, error) {
+			t.Error("a coalesced waiter must not run its own fetch")
+			return "", nil
+		})
+	}()
+
+	// Give the waiter time to arrive and coalesce onto the in-flight call
+	// before letting the winner's fetch panic.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-waiterDone:
+	case <-time.After(time.Second):
+		t.Fatal("coalesced waiter blocked forever on a panicking fetch")
+	}
+	<-winnerDone
+
+	g.Expect(waitErr).To(HaveOccurred(), "a coalesced waiter must observe the panic as an error, not a zero-value success")
+	g.Expect(errors.Is(waitErr, errFetchPanicked)).To(BeTrue())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(value).To(Equal(""))
+}
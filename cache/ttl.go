@@ -0,0 +1,40 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import "time"
+
+// WithDefaultTTL sets a default time-to-live applied to entries written
+// through Set when no per-entry TTL is supplied via SetWithTTL. Without this
+// option, entries never expire unless SetWithTTL is used directly.
+func WithDefaultTTL(d time.Duration) Options {
+	return func(o *options) error {
+		o.defaultTTL = d
+		return nil
+	}
+}
+
+// WithExpirationInterval starts a background goroutine that walks the cache
+// every d, evicting entries whose TTL has elapsed. Without this option,
+// expired entries are only reclaimed lazily, the next time they are looked up
+// through Get or GetIfOrSet.
+func WithExpirationInterval(d time.Duration) Options {
+	return func(o *options) error {
+		o.expirationInterval = d
+		return nil
+	}
+}
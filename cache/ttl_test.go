@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLRU_GetTreatsExpiredEntryAsAbsent(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.SetWithTTL("a", "1", 10*time.Millisecond)).To(Succeed())
+
+	v, err := c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(v).To(Equal("1"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.Get("a")
+	g.Expect(err).To(MatchError(ErrNotFound))
+
+	keys, err := c.ListKeys()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(keys).To(BeEmpty(), "expired entry must be unlinked by the lazy Get path, not just hidden")
+}
+
+func TestLRU_GetIfOrSetTreatsExpiredEntryAsMiss(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ctx := context.Background()
+	alwaysHit := func(string) bool { return true }
+
+	_, _, err = c.GetIfOrSet(ctx, "a", alwaysHit, func(context.Context) (string, error) {
+		return "1", nil
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.SetWithTTL("a", "1", time.Millisecond)).To(Succeed())
+	time.Sleep(10 * time.Millisecond)
+
+	var fetched bool
+	v, ok, err := c.GetIfOrSet(ctx, "a", alwaysHit, func(context.Context) (string, error) {
+		fetched = true
+		return "2", nil
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeFalse(), "an expired entry must not be reported as a hit")
+	g.Expect(fetched).To(BeTrue(), "fetch must run for an expired key, the same as a true miss")
+	g.Expect(v).To(Equal("2"))
+
+	keys, err := c.ListKeys()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(keys).To(ConsistOf("a"), "the refresh must replace the expired entry, not leave two")
+}
+
+func TestLRU_ExpirationSweeperEvictsInBackground(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10, WithExpirationInterval(5*time.Millisecond))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer c.Close()
+
+	g.Expect(c.SetWithTTL("a", "1", time.Millisecond)).To(Succeed())
+
+	g.Eventually(func() ([]string, error) {
+		return c.ListKeys()
+	}).WithTimeout(time.Second).WithPolling(5*time.Millisecond).Should(BeEmpty())
+}
+
+func TestLRU_CloseIsIdempotent(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10, WithExpirationInterval(5*time.Millisecond))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Close()).To(Succeed())
+	g.Expect(func() { _ = c.Close() }).NotTo(Panic())
+}
+
+func TestLRU_SetWithTTLZeroNeverExpires(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewLRU[string](10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.SetWithTTL("a", "1", 0)).To(Succeed())
+	time.Sleep(10 * time.Millisecond)
+
+	v, err := c.Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(v).To(Equal("1"))
+}